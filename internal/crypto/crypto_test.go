@@ -0,0 +1,96 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func mustKey(t *testing.T, size int) []byte {
+	t.Helper()
+	key := make([]byte, size)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	return key
+}
+
+func TestSealerRoundTrip(t *testing.T) {
+	for _, algorithm := range []Algorithm{AlgorithmAES256GCM, AlgorithmXChaCha20Poly1305} {
+		keySize := 32
+		sealer, err := NewSealer(algorithm, 1, mustKey(t, keySize))
+		if err != nil {
+			t.Fatalf("NewSealer(%d): %v", algorithm, err)
+		}
+
+		plaintext := []byte("fastmail token")
+		sealed, err := sealer.Seal(plaintext)
+		if err != nil {
+			t.Fatalf("Seal: %v", err)
+		}
+
+		opened, err := sealer.Open(sealed)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+
+		if !bytes.Equal(opened, plaintext) {
+			t.Fatalf("Open() = %q, want %q", opened, plaintext)
+		}
+	}
+}
+
+func TestSealerOpenRejectsTamperedCiphertext(t *testing.T) {
+	sealer, err := NewSealer(AlgorithmAES256GCM, 1, mustKey(t, 32))
+	if err != nil {
+		t.Fatalf("NewSealer: %v", err)
+	}
+
+	sealed, err := sealer.Seal([]byte("fastmail token"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	sealed[len(sealed)-1] ^= 0xFF
+
+	if _, err := sealer.Open(sealed); err == nil {
+		t.Fatal("expected Open to reject tampered ciphertext")
+	}
+}
+
+func TestKeyRingOpensUnderPreviousKeyVersion(t *testing.T) {
+	oldSealer, err := NewSealer(AlgorithmAES256GCM, 1, mustKey(t, 32))
+	if err != nil {
+		t.Fatalf("NewSealer(old): %v", err)
+	}
+
+	newSealer, err := NewSealer(AlgorithmAES256GCM, 2, mustKey(t, 32))
+	if err != nil {
+		t.Fatalf("NewSealer(new): %v", err)
+	}
+
+	plaintext := []byte("fastmail token")
+	sealed, err := oldSealer.Seal(plaintext)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	ring := NewKeyRing(newSealer, oldSealer)
+
+	opened, err := ring.Open(sealed)
+	if err != nil {
+		t.Fatalf("KeyRing.Open of a value sealed under a previous key: %v", err)
+	}
+
+	if !bytes.Equal(opened, plaintext) {
+		t.Fatalf("Open() = %q, want %q", opened, plaintext)
+	}
+
+	resealed, err := ring.Seal(plaintext)
+	if err != nil {
+		t.Fatalf("KeyRing.Seal: %v", err)
+	}
+
+	if resealed[1] != 2 {
+		t.Fatalf("expected KeyRing to seal under the current key version 2, got %d", resealed[1])
+	}
+}
@@ -0,0 +1,31 @@
+package crypto
+
+import (
+	"context"
+	"encoding/base64"
+	"gocloud.dev/secrets"
+
+	// KMS drivers registered against gocloud.dev/secrets by URL scheme.
+	_ "gocloud.dev/secrets/awskms"
+	_ "gocloud.dev/secrets/gcpkms"
+)
+
+// LoadKey resolves the raw key material a Sealer is built from.
+//
+// If wrappedKey is nil, ref is decoded as base64 and returned as the raw key
+// itself (the Config.EncryptionKey case). Otherwise ref is treated as a
+// gocloud.dev/secrets keeper URL (awskms://..., gcpkms://...) and wrappedKey
+// is unwrapped through that KMS.
+func LoadKey(ctx context.Context, ref string, wrappedKey []byte) ([]byte, error) {
+	if wrappedKey == nil {
+		return base64.StdEncoding.DecodeString(ref)
+	}
+
+	keeper, err := secrets.OpenKeeper(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	defer keeper.Close()
+
+	return keeper.Decrypt(ctx, wrappedKey)
+}
@@ -0,0 +1,8 @@
+package domain
+
+// BroadcastRecipient is a thin projection of User used when paginating
+// over the whole user base, e.g. for broadcasts.
+type BroadcastRecipient struct {
+	TelegramID   int64
+	LanguageCode string
+}
@@ -0,0 +1,16 @@
+package domain
+
+import "errors"
+
+// ErrNoPin is returned when a verification PIN does not exist, was already
+// consumed or has expired.
+var ErrNoPin = errors.New("no pin")
+
+// ErrInternal is returned for unexpected backend failures, regardless of
+// which domain.Database implementation hit them.
+var ErrInternal = errors.New("internal error")
+
+// ErrUserAlreadyExists is returned by CreateUser when a user with the given
+// telegram_id already exists, regardless of which domain.Database
+// implementation enforced the constraint.
+var ErrUserAlreadyExists = errors.New("user already exists")
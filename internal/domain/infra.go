@@ -4,17 +4,38 @@ import (
 	"context"
 	"golang.org/x/oauth2"
 	"net/url"
+	"time"
 )
 
 type Database interface {
 	CreateUser(telegramID int64, languageCode string) error
 	UpdateToken(telegramID int64, fastmailToken string) error
+	// ClearToken disconnects the user's Fastmail account, e.g. on /revoke.
+	ClearToken(telegramID int64) error
 	UpdateLanguageCode(telegramID int64, languageCode string) error
 	GetUser(telegramID int64) (*User, error)
 
 	CreateOAuth2State(state, codeVerifier string, telegramID int64) error
 	GetOAuth2State(state string) (*OAuth2State, error)
 
+	// CreatePin stores a short-lived PIN generated on the OAuth2 callback page
+	// together with the Fastmail token it exchanged. The token is held here,
+	// not written to the user's row, until ConsumePin confirms the chat
+	// sending the PIN back is the same Telegram account the PIN was issued to.
+	CreatePin(telegramID int64, pin, fastmailToken string, expiresAt time.Time) error
+	// ConsumePin looks up and deletes a PIN, returning the Telegram ID it was
+	// issued for and the Fastmail token that was pending confirmation. It
+	// returns ErrNoPin if the PIN is unknown, already used or expired.
+	ConsumePin(pin string) (telegramID int64, fastmailToken string, err error)
+
+	// ListUsers returns up to limit users with a telegram_id greater than cursor,
+	// ordered by telegram_id, along with the cursor to pass on the next call.
+	// A returned cursor of 0 means there are no more users to list.
+	ListUsers(ctx context.Context, cursor int64, limit int) ([]*BroadcastRecipient, int64, error)
+	// BlockUser marks a user as having blocked the bot, so they are skipped by
+	// future broadcasts.
+	BlockUser(telegramID int64) error
+
 	Close() error
 	NewTokenSource(baseTokenSource oauth2.TokenSource, telegramID int64) oauth2.TokenSource
 }
@@ -33,4 +54,7 @@ type Delivery interface {
 
 type Telegram interface {
 	SendMessage(telegramID int64, languageCode, messageID string) error
+	// Broadcast sends a localized message built from messageID and args to
+	// every user, respecting Telegram's rate limits and skipping blocked users.
+	Broadcast(ctx context.Context, messageID string, args map[string]any) error
 }
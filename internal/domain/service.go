@@ -0,0 +1,10 @@
+package domain
+
+import "context"
+
+// Service is the application layer telegram.delivery talks to: it resolves
+// a Telegram user's Fastmail token source and drives MaskingEmail on their
+// behalf, keeping OAuth2 plumbing out of the delivery layer.
+type Service interface {
+	CreateMaskedEmailWithPrefix(ctx context.Context, telegramID int64, prefix string) (*MaskedEmail, error)
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"github.com/L11R/masked-email-bot/internal/domain"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"html/template"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+const pinTTL = 10 * time.Minute
+
+var callbackTemplate = template.Must(template.New("callback").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Masked Email Bot</title></head>
+<body>
+<p>Almost done. To finish linking your Fastmail account, send this PIN to the bot in Telegram:</p>
+<h1>{{.Pin}}</h1>
+</body>
+</html>`))
+
+type handler struct {
+	logger       *zap.Logger
+	database     domain.Database
+	maskingEmail domain.MaskingEmail
+}
+
+// NewHandler returns an http.Handler serving the Fastmail OAuth2 callback.
+// On success it stores the Fastmail token and issues a short-lived PIN that
+// the user relays back to the bot to prove the browser session is theirs.
+func NewHandler(logger *zap.Logger, database domain.Database, maskingEmail domain.MaskingEmail) http.Handler {
+	mux := http.NewServeMux()
+	h := &handler{logger: logger, database: database, maskingEmail: maskingEmail}
+	mux.HandleFunc("/oauth2/callback", h.callback)
+
+	return mux
+}
+
+func (h *handler) callback(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	code := r.URL.Query().Get("code")
+
+	oauth2State, err := h.database.GetOAuth2State(state)
+	if err != nil {
+		h.logger.Error("Error while getting an OAuth2 state!", zap.Error(err))
+		http.Error(w, "invalid state", http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.maskingEmail.GetOAuth2Config().Exchange(
+		r.Context(),
+		code,
+		oauth2.SetAuthURLParam("code_verifier", oauth2State.CodeVerifier),
+	)
+	if err != nil {
+		h.logger.Error("Error while exchanging an OAuth2 code!", zap.Error(err))
+		http.Error(w, "failed to exchange code", http.StatusBadGateway)
+		return
+	}
+
+	b, err := json.Marshal(token)
+	if err != nil {
+		h.logger.Error("Error while encoding a Fastmail token!", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	pin, err := generatePin()
+	if err != nil {
+		h.logger.Error("Error while generating a verification pin!", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	// The token is held against the PIN, not written to the user's row yet:
+	// until the same Telegram account that requested it sends the PIN back,
+	// we have no proof the browser session belongs to them rather than an
+	// attacker who planted this callback's `state`.
+	if err := h.database.CreatePin(oauth2State.TelegramID, pin, string(b), time.Now().Add(pinTTL)); err != nil {
+		h.logger.Error("Error while creating a verification pin!", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := callbackTemplate.Execute(w, struct{ Pin string }{Pin: pin}); err != nil {
+		h.logger.Error("Error while rendering the callback page!", zap.Error(err))
+	}
+}
+
+func generatePin() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
@@ -0,0 +1,345 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/L11R/masked-email-bot/internal/domain"
+	"github.com/L11R/masked-email-bot/internal/infra/sqlcommon"
+	"github.com/golang-migrate/migrate/v4"
+	postgresmigrate "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/oauth2"
+
+	// file driver for the golang-migrate
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"database/sql"
+	// registers the "pgx" driver with database/sql, used to run migrations
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"go.uber.org/zap"
+	"time"
+)
+
+// uniqueViolation is the Postgres error code for a unique constraint
+// violation, the equivalent of SQLite's extended code 1555.
+const uniqueViolation = "23505"
+
+type adapter struct {
+	logger *zap.Logger
+	config *Config
+	pool   *pgxpool.Pool
+	sealer sqlcommon.Sealer
+}
+
+// NewAdapter opens the connection pool and applies pending migrations.
+// sealer may be nil, in which case Fastmail tokens are stored in plaintext,
+// as before.
+func NewAdapter(ctx context.Context, logger *zap.Logger, config *Config, sealer sqlcommon.Sealer) (domain.Database, error) {
+	pool, err := pgxpool.New(ctx, config.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	// Migrations block
+	db, err := sql.Open("pgx", config.DSN)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	driver, err := postgresmigrate.WithInstance(db, &postgresmigrate.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := migrate.NewWithDatabaseInstance(config.MigrationsSourceURL, config.Name, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return nil, err
+	}
+
+	return &adapter{
+		logger: logger,
+		config: config,
+		pool:   pool,
+		sealer: sealer,
+	}, nil
+}
+
+func (a *adapter) CreateUser(telegramID int64, languageCode string) error {
+	_, err := a.pool.Exec(
+		context.Background(),
+		`INSERT INTO users (telegram_id, lang) VALUES ($1, $2)`,
+		telegramID,
+		languageCode,
+	)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		a.logger.Info("Duplicate key value violation!", zap.Error(err))
+		return domain.ErrUserAlreadyExists
+	} else if err != nil {
+		a.logger.Error("Error while creating a user!", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+func (a *adapter) UpdateToken(telegramID int64, fastmailToken string) error {
+	sealed, err := sqlcommon.SealToken(a.sealer, fastmailToken)
+	if err != nil {
+		a.logger.Error("Error while sealing a token!", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	_, err = a.pool.Exec(
+		context.Background(),
+		`UPDATE users SET fastmail_token = $1 WHERE telegram_id = $2`,
+		sealed,
+		telegramID,
+	)
+	if err != nil {
+		a.logger.Error("Error while updating a token!", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+func (a *adapter) ClearToken(telegramID int64) error {
+	_, err := a.pool.Exec(
+		context.Background(),
+		`UPDATE users SET fastmail_token = NULL WHERE telegram_id = $1`,
+		telegramID,
+	)
+	if err != nil {
+		a.logger.Error("Error while clearing a token!", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+func (a *adapter) UpdateLanguageCode(telegramID int64, languageCode string) error {
+	_, err := a.pool.Exec(
+		context.Background(),
+		`UPDATE users SET lang = $1 WHERE telegram_id = $2`,
+		languageCode,
+		telegramID,
+	)
+	if err != nil {
+		a.logger.Error("Error while updating a language code!", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+func (a *adapter) GetUser(telegramID int64) (*domain.User, error) {
+	row := a.pool.QueryRow(
+		context.Background(),
+		`SELECT telegram_id, fastmail_token, lang FROM users WHERE telegram_id = $1`,
+		telegramID,
+	)
+
+	var user domain.User
+	var tokenStr *string
+	if err := row.Scan(
+		&user.TelegramID,
+		&tokenStr,
+		&user.LanguageCode,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNoUser
+		}
+
+		a.logger.Error("Error while getting a user!", zap.Error(err))
+		return nil, domain.ErrInternal
+	}
+
+	if tokenStr != nil {
+		opened, err := sqlcommon.OpenToken(a.sealer, *tokenStr)
+		if err != nil {
+			a.logger.Error("Error while opening a Fastmail token!", zap.Error(err))
+			return nil, domain.ErrInternal
+		}
+
+		token, err := sqlcommon.UnmarshalToken(opened)
+		if err != nil {
+			a.logger.Error("Error while decoding a Fastmail token!", zap.Error(err))
+			return nil, domain.ErrInternal
+		}
+
+		user.FastmailToken = token
+	}
+
+	return &user, nil
+}
+
+func (a *adapter) CreateOAuth2State(state, codeVerifier string, telegramID int64) error {
+	_, err := a.pool.Exec(
+		context.Background(),
+		`INSERT INTO oauth2_states (state, code_verifier, telegram_id) VALUES ($1, $2, $3)`,
+		state,
+		codeVerifier,
+		telegramID,
+	)
+	if err != nil {
+		a.logger.Error("Error while creating an OAuth2 state!", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+func (a *adapter) GetOAuth2State(state string) (*domain.OAuth2State, error) {
+	row := a.pool.QueryRow(
+		context.Background(),
+		`SELECT code_verifier, telegram_id FROM oauth2_states WHERE state = $1`,
+		state,
+	)
+
+	var oauth2State domain.OAuth2State
+	oauth2State.State = state
+	if err := row.Scan(
+		&oauth2State.CodeVerifier,
+		&oauth2State.TelegramID,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNoState
+		}
+
+		a.logger.Error("Error while getting an OAuth2 state!", zap.Error(err))
+		return nil, domain.ErrInternal
+	}
+
+	return &oauth2State, nil
+}
+
+func (a *adapter) CreatePin(telegramID int64, pin, fastmailToken string, expiresAt time.Time) error {
+	sealed, err := sqlcommon.SealToken(a.sealer, fastmailToken)
+	if err != nil {
+		a.logger.Error("Error while sealing a pending Fastmail token!", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	_, err = a.pool.Exec(
+		context.Background(),
+		`INSERT INTO verification_pins (pin, telegram_id, fastmail_token, expires_at) VALUES ($1, $2, $3, $4)`,
+		pin,
+		telegramID,
+		sealed,
+		expiresAt,
+	)
+	if err != nil {
+		a.logger.Error("Error while creating a verification pin!", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+func (a *adapter) ConsumePin(pin string) (int64, string, error) {
+	row := a.pool.QueryRow(
+		context.Background(),
+		`SELECT telegram_id, fastmail_token, expires_at FROM verification_pins WHERE pin = $1`,
+		pin,
+	)
+
+	var telegramID int64
+	var sealed string
+	var expiresAt time.Time
+	if err := row.Scan(&telegramID, &sealed, &expiresAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, "", domain.ErrNoPin
+		}
+
+		a.logger.Error("Error while getting a verification pin!", zap.Error(err))
+		return 0, "", domain.ErrInternal
+	}
+
+	if _, err := a.pool.Exec(context.Background(), `DELETE FROM verification_pins WHERE pin = $1`, pin); err != nil {
+		a.logger.Error("Error while deleting a verification pin!", zap.Error(err))
+		return 0, "", domain.ErrInternal
+	}
+
+	if time.Now().After(expiresAt) {
+		return 0, "", domain.ErrNoPin
+	}
+
+	fastmailToken, err := sqlcommon.OpenToken(a.sealer, sealed)
+	if err != nil {
+		a.logger.Error("Error while opening a pending Fastmail token!", zap.Error(err))
+		return 0, "", domain.ErrInternal
+	}
+
+	return telegramID, fastmailToken, nil
+}
+
+func (a *adapter) ListUsers(ctx context.Context, cursor int64, limit int) ([]*domain.BroadcastRecipient, int64, error) {
+	rows, err := a.pool.Query(
+		ctx,
+		`SELECT telegram_id, lang FROM users WHERE telegram_id > $1 AND blocked_at IS NULL ORDER BY telegram_id LIMIT $2`,
+		cursor,
+		limit,
+	)
+	if err != nil {
+		a.logger.Error("Error while listing users!", zap.Error(err))
+		return nil, 0, domain.ErrInternal
+	}
+	defer rows.Close()
+
+	var recipients []*domain.BroadcastRecipient
+	for rows.Next() {
+		var recipient domain.BroadcastRecipient
+		if err := rows.Scan(&recipient.TelegramID, &recipient.LanguageCode); err != nil {
+			a.logger.Error("Error while scanning a user!", zap.Error(err))
+			return nil, 0, domain.ErrInternal
+		}
+
+		recipients = append(recipients, &recipient)
+	}
+	if err := rows.Err(); err != nil {
+		a.logger.Error("Error while listing users!", zap.Error(err))
+		return nil, 0, domain.ErrInternal
+	}
+
+	var nextCursor int64
+	if len(recipients) == limit {
+		nextCursor = recipients[len(recipients)-1].TelegramID
+	}
+
+	return recipients, nextCursor, nil
+}
+
+func (a *adapter) BlockUser(telegramID int64) error {
+	_, err := a.pool.Exec(
+		context.Background(),
+		`UPDATE users SET blocked_at = now() WHERE telegram_id = $1`,
+		telegramID,
+	)
+	if err != nil {
+		a.logger.Error("Error while blocking a user!", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+func (a *adapter) Close() error {
+	a.pool.Close()
+	return nil
+}
+
+func (a *adapter) NewTokenSource(baseTokenSource oauth2.TokenSource, telegramID int64) oauth2.TokenSource {
+	return &sqlcommon.TokenSource{
+		Database:        a,
+		BaseTokenSource: baseTokenSource,
+		TelegramID:      telegramID,
+	}
+}
@@ -0,0 +1,7 @@
+package postgres
+
+type Config struct {
+	DSN                 string
+	MigrationsSourceURL string
+	Name                string
+}
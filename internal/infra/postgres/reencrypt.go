@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"context"
+	"github.com/L11R/masked-email-bot/internal/infra/sqlcommon"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Reencrypt opens every stored Fastmail token with oldSealer and re-seals it
+// with newSealer, letting an encryption key be rotated without downtime.
+// Either sealer may be nil to read or write plaintext.
+func Reencrypt(ctx context.Context, pool *pgxpool.Pool, oldSealer, newSealer sqlcommon.Sealer) error {
+	rows, err := pool.Query(ctx, `SELECT telegram_id, fastmail_token FROM users WHERE fastmail_token IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var toReencrypt []sqlcommon.TokenRow
+	for rows.Next() {
+		var r sqlcommon.TokenRow
+		if err := rows.Scan(&r.TelegramID, &r.Token); err != nil {
+			return err
+		}
+
+		toReencrypt = append(toReencrypt, r)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	reencrypted, err := sqlcommon.Reencrypt(toReencrypt, oldSealer, newSealer)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range reencrypted {
+		if _, err := pool.Exec(ctx, `UPDATE users SET fastmail_token = $1 WHERE telegram_id = $2`, r.Token, r.TelegramID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package sqlcommon
+
+// TokenRow is one stored Fastmail token as read from the users table, ready
+// to be re-sealed by Reencrypt.
+type TokenRow struct {
+	TelegramID int64
+	Token      string
+}
+
+// Reencrypt opens every row's token with oldSealer and re-seals it with
+// newSealer, letting an encryption key be rotated without downtime. Either
+// sealer may be nil to read or write plaintext. It returns the rows with
+// Token replaced by the newly sealed value; the caller is responsible for
+// reading rows from, and writing them back to, its own backend.
+func Reencrypt(rows []TokenRow, oldSealer, newSealer Sealer) ([]TokenRow, error) {
+	reencrypted := make([]TokenRow, len(rows))
+	for i, r := range rows {
+		plaintext, err := OpenToken(oldSealer, r.Token)
+		if err != nil {
+			return nil, err
+		}
+
+		sealed, err := SealToken(newSealer, plaintext)
+		if err != nil {
+			return nil, err
+		}
+
+		reencrypted[i] = TokenRow{TelegramID: r.TelegramID, Token: sealed}
+	}
+
+	return reencrypted, nil
+}
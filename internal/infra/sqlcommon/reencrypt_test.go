@@ -0,0 +1,67 @@
+package sqlcommon
+
+import "testing"
+
+type fakeSealer struct {
+	prefix string
+}
+
+func (f fakeSealer) Seal(plaintext []byte) ([]byte, error) {
+	return append([]byte(f.prefix), plaintext...), nil
+}
+
+func (f fakeSealer) Open(ciphertext []byte) ([]byte, error) {
+	return ciphertext[len(f.prefix):], nil
+}
+
+func TestReencrypt(t *testing.T) {
+	oldSealer := fakeSealer{prefix: "old:"}
+	newSealer := fakeSealer{prefix: "new:"}
+
+	sealed, err := SealToken(oldSealer, "plaintext-token")
+	if err != nil {
+		t.Fatalf("SealToken: %v", err)
+	}
+
+	rows := []TokenRow{{TelegramID: 42, Token: sealed}}
+
+	reencrypted, err := Reencrypt(rows, oldSealer, newSealer)
+	if err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+
+	if len(reencrypted) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(reencrypted))
+	}
+
+	if reencrypted[0].TelegramID != 42 {
+		t.Fatalf("expected TelegramID 42, got %d", reencrypted[0].TelegramID)
+	}
+
+	opened, err := OpenToken(newSealer, reencrypted[0].Token)
+	if err != nil {
+		t.Fatalf("OpenToken: %v", err)
+	}
+
+	if opened != "plaintext-token" {
+		t.Fatalf("OpenToken() = %q, want %q", opened, "plaintext-token")
+	}
+}
+
+func TestReencryptToPlaintext(t *testing.T) {
+	oldSealer := fakeSealer{prefix: "old:"}
+
+	sealed, err := SealToken(oldSealer, "plaintext-token")
+	if err != nil {
+		t.Fatalf("SealToken: %v", err)
+	}
+
+	reencrypted, err := Reencrypt([]TokenRow{{TelegramID: 1, Token: sealed}}, oldSealer, nil)
+	if err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+
+	if reencrypted[0].Token != "plaintext-token" {
+		t.Fatalf("expected plaintext token after reencrypting with a nil sealer, got %q", reencrypted[0].Token)
+	}
+}
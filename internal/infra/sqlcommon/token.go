@@ -0,0 +1,111 @@
+// Package sqlcommon holds the logic shared by every SQL-backed
+// domain.Database adapter (sqlite, postgres, ...), so adding a new backend
+// doesn't mean re-implementing token handling from scratch.
+package sqlcommon
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/L11R/masked-email-bot/internal/domain"
+	"golang.org/x/oauth2"
+)
+
+// Sealer seals and opens the token blob before it reaches disk. It is
+// satisfied by *crypto.Sealer and *crypto.KeyRing. A nil Sealer is a no-op,
+// so encryption at rest remains opt-in.
+type Sealer interface {
+	Seal(plaintext []byte) ([]byte, error)
+	Open(ciphertext []byte) ([]byte, error)
+}
+
+// SealToken seals a marshaled token for storage. Sealed output is
+// base64-encoded so it still fits in a TEXT column.
+func SealToken(sealer Sealer, plaintext string) (string, error) {
+	if sealer == nil {
+		return plaintext, nil
+	}
+
+	sealed, err := sealer.Seal([]byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenToken reverses SealToken.
+func OpenToken(sealer Sealer, stored string) (string, error) {
+	if sealer == nil {
+		return stored, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(stored)
+	if err != nil {
+		return "", err
+	}
+
+	opened, err := sealer.Open(sealed)
+	if err != nil {
+		return "", err
+	}
+
+	return string(opened), nil
+}
+
+// MarshalToken encodes an oauth2.Token the same way every adapter stores it
+// in the fastmail_token column.
+func MarshalToken(token *oauth2.Token) (string, error) {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// UnmarshalToken decodes an oauth2.Token stored by MarshalToken.
+func UnmarshalToken(s string) (*oauth2.Token, error) {
+	var token oauth2.Token
+	if err := json.Unmarshal([]byte(s), &token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// TokenSource is an oauth2.TokenSource that serves the token stored in
+// Database while it is valid; otherwise it takes a new one from
+// BaseTokenSource and persists it to Database. It only depends on
+// domain.Database, so it's identical across every SQL backend.
+type TokenSource struct {
+	Database        domain.Database
+	BaseTokenSource oauth2.TokenSource
+	TelegramID      int64
+}
+
+func (ts *TokenSource) Token() (*oauth2.Token, error) {
+	user, err := ts.Database.GetUser(ts.TelegramID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.FastmailToken.Valid() {
+		return user.FastmailToken, nil
+	}
+
+	token, err := ts.BaseTokenSource.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := MarshalToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ts.Database.UpdateToken(ts.TelegramID, b); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
@@ -1,10 +1,11 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"github.com/L11R/masked-email-bot/internal/domain"
+	"github.com/L11R/masked-email-bot/internal/infra/sqlcommon"
 	"github.com/golang-migrate/migrate/v4"
 	sqlite3migrate "github.com/golang-migrate/migrate/v4/database/sqlite3"
 	"github.com/mattn/go-sqlite3"
@@ -16,15 +17,20 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 	"go.uber.org/zap"
 	"log"
+	"time"
 )
 
 type adapter struct {
 	logger *zap.Logger
 	config *Config
 	db     *sql.DB
+	sealer sqlcommon.Sealer
 }
 
-func NewAdapter(logger *zap.Logger, config *Config) (domain.Database, error) {
+// NewAdapter opens the SQLite database and applies pending migrations.
+// sealer may be nil, in which case Fastmail tokens are stored in plaintext,
+// as before.
+func NewAdapter(logger *zap.Logger, config *Config, sealer sqlcommon.Sealer) (domain.Database, error) {
 	db, err := sql.Open("sqlite3", config.DBFile)
 	if err != nil {
 		log.Fatal(err)
@@ -49,6 +55,7 @@ func NewAdapter(logger *zap.Logger, config *Config) (domain.Database, error) {
 		logger: logger,
 		config: config,
 		db:     db,
+		sealer: sealer,
 	}, nil
 }
 
@@ -62,24 +69,43 @@ func (a *adapter) CreateUser(telegramID int64, languageCode string) error {
 	var sqliteErr sqlite3.Error
 	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == 1555 {
 		a.logger.Info("Duplicate key value violation!", zap.Error(err))
-		return domain.ErrSqliteUserAlreadyExists
+		return domain.ErrUserAlreadyExists
 	} else if err != nil {
 		a.logger.Error("Error while creating a user!", zap.Error(err))
-		return domain.ErrSqliteInternal
+		return domain.ErrInternal
 	}
 
 	return nil
 }
 
 func (a *adapter) UpdateToken(telegramID int64, fastmailToken string) error {
-	_, err := a.db.Exec(
+	sealed, err := sqlcommon.SealToken(a.sealer, fastmailToken)
+	if err != nil {
+		a.logger.Error("Error while sealing a token!", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	_, err = a.db.Exec(
 		`UPDATE users SET fastmail_token = ? WHERE telegram_id = ?`,
-		fastmailToken,
+		sealed,
 		telegramID,
 	)
 	if err != nil {
 		a.logger.Error("Error while updating a token!", zap.Error(err))
-		return domain.ErrSqliteInternal
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+func (a *adapter) ClearToken(telegramID int64) error {
+	_, err := a.db.Exec(
+		`UPDATE users SET fastmail_token = NULL WHERE telegram_id = ?`,
+		telegramID,
+	)
+	if err != nil {
+		a.logger.Error("Error while clearing a token!", zap.Error(err))
+		return domain.ErrInternal
 	}
 
 	return nil
@@ -93,7 +119,7 @@ func (a *adapter) UpdateLanguageCode(telegramID int64, languageCode string) erro
 	)
 	if err != nil {
 		a.logger.Error("Error while updating a language code!", zap.Error(err))
-		return domain.ErrSqliteInternal
+		return domain.ErrInternal
 	}
 
 	return nil
@@ -117,14 +143,23 @@ func (a *adapter) GetUser(telegramID int64) (*domain.User, error) {
 		}
 
 		a.logger.Error("Error while getting a user!", zap.Error(err))
-		return nil, domain.ErrSqliteInternal
+		return nil, domain.ErrInternal
 	}
 
 	if tokenStr.Valid {
-		if err := json.Unmarshal([]byte(tokenStr.String), &user.FastmailToken); err != nil {
+		opened, err := sqlcommon.OpenToken(a.sealer, tokenStr.String)
+		if err != nil {
+			a.logger.Error("Error while opening a Fastmail token!", zap.Error(err))
+			return nil, domain.ErrInternal
+		}
+
+		token, err := sqlcommon.UnmarshalToken(opened)
+		if err != nil {
 			a.logger.Error("Error while decoding a Fastmail token!", zap.Error(err))
-			return nil, domain.ErrSqliteInternal
+			return nil, domain.ErrInternal
 		}
+
+		user.FastmailToken = token
 	}
 
 	return &user, nil
@@ -139,7 +174,7 @@ func (a *adapter) CreateOAuth2State(state, codeVerifier string, telegramID int64
 	)
 	if err != nil {
 		a.logger.Error("Error while creating an OAuth2 state!", zap.Error(err))
-		return domain.ErrSqliteInternal
+		return domain.ErrInternal
 	}
 
 	return nil
@@ -162,53 +197,127 @@ func (a *adapter) GetOAuth2State(state string) (*domain.OAuth2State, error) {
 		}
 
 		a.logger.Error("Error while getting an OAuth2 state!", zap.Error(err))
-		return nil, domain.ErrSqliteInternal
+		return nil, domain.ErrInternal
 	}
 
 	return &oauth2State, nil
 }
 
-func (a *adapter) Close() error {
-	return a.db.Close()
-}
+func (a *adapter) CreatePin(telegramID int64, pin, fastmailToken string, expiresAt time.Time) error {
+	sealed, err := sqlcommon.SealToken(a.sealer, fastmailToken)
+	if err != nil {
+		a.logger.Error("Error while sealing a pending Fastmail token!", zap.Error(err))
+		return domain.ErrInternal
+	}
 
-func (a *adapter) NewTokenSource(baseTokenSource oauth2.TokenSource, telegramID int64) oauth2.TokenSource {
-	return &tokenSource{
-		database:        a,
-		baseTokenSource: baseTokenSource,
-		telegramID:      telegramID,
+	_, err = a.db.Exec(
+		`INSERT INTO verification_pins (pin, telegram_id, fastmail_token, expires_at) VALUES (?, ?, ?, ?)`,
+		pin,
+		telegramID,
+		sealed,
+		expiresAt,
+	)
+	if err != nil {
+		a.logger.Error("Error while creating a verification pin!", zap.Error(err))
+		return domain.ErrInternal
 	}
-}
 
-type tokenSource struct {
-	database        domain.Database
-	baseTokenSource oauth2.TokenSource
-	telegramID      int64
+	return nil
 }
 
-func (ts *tokenSource) Token() (*oauth2.Token, error) {
-	user, err := ts.database.GetUser(ts.telegramID)
-	if err != nil {
-		return nil, err
+func (a *adapter) ConsumePin(pin string) (int64, string, error) {
+	row := a.db.QueryRow(
+		`SELECT telegram_id, fastmail_token, expires_at FROM verification_pins WHERE pin = ?`,
+		pin,
+	)
+
+	var telegramID int64
+	var sealed string
+	var expiresAt time.Time
+	if err := row.Scan(&telegramID, &sealed, &expiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, "", domain.ErrNoPin
+		}
+
+		a.logger.Error("Error while getting a verification pin!", zap.Error(err))
+		return 0, "", domain.ErrInternal
+	}
+
+	if _, err := a.db.Exec(`DELETE FROM verification_pins WHERE pin = ?`, pin); err != nil {
+		a.logger.Error("Error while deleting a verification pin!", zap.Error(err))
+		return 0, "", domain.ErrInternal
 	}
 
-	if user.FastmailToken.Valid() {
-		return user.FastmailToken, nil
+	if time.Now().After(expiresAt) {
+		return 0, "", domain.ErrNoPin
 	}
 
-	token, err := ts.baseTokenSource.Token()
+	fastmailToken, err := sqlcommon.OpenToken(a.sealer, sealed)
 	if err != nil {
-		return nil, err
+		a.logger.Error("Error while opening a pending Fastmail token!", zap.Error(err))
+		return 0, "", domain.ErrInternal
 	}
 
-	b, err := json.Marshal(token)
+	return telegramID, fastmailToken, nil
+}
+
+func (a *adapter) ListUsers(ctx context.Context, cursor int64, limit int) ([]*domain.BroadcastRecipient, int64, error) {
+	rows, err := a.db.QueryContext(
+		ctx,
+		`SELECT telegram_id, lang FROM users WHERE telegram_id > ? AND blocked_at IS NULL ORDER BY telegram_id LIMIT ?`,
+		cursor,
+		limit,
+	)
 	if err != nil {
-		return nil, err
+		a.logger.Error("Error while listing users!", zap.Error(err))
+		return nil, 0, domain.ErrInternal
 	}
+	defer rows.Close()
+
+	var recipients []*domain.BroadcastRecipient
+	for rows.Next() {
+		var recipient domain.BroadcastRecipient
+		if err := rows.Scan(&recipient.TelegramID, &recipient.LanguageCode); err != nil {
+			a.logger.Error("Error while scanning a user!", zap.Error(err))
+			return nil, 0, domain.ErrInternal
+		}
 
-	if err := ts.database.UpdateToken(ts.telegramID, string(b)); err != nil {
-		return nil, err
+		recipients = append(recipients, &recipient)
+	}
+	if err := rows.Err(); err != nil {
+		a.logger.Error("Error while listing users!", zap.Error(err))
+		return nil, 0, domain.ErrInternal
 	}
 
-	return token, nil
+	var nextCursor int64
+	if len(recipients) == limit {
+		nextCursor = recipients[len(recipients)-1].TelegramID
+	}
+
+	return recipients, nextCursor, nil
+}
+
+func (a *adapter) BlockUser(telegramID int64) error {
+	_, err := a.db.Exec(
+		`UPDATE users SET blocked_at = CURRENT_TIMESTAMP WHERE telegram_id = ?`,
+		telegramID,
+	)
+	if err != nil {
+		a.logger.Error("Error while blocking a user!", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+func (a *adapter) Close() error {
+	return a.db.Close()
+}
+
+func (a *adapter) NewTokenSource(baseTokenSource oauth2.TokenSource, telegramID int64) oauth2.TokenSource {
+	return &sqlcommon.TokenSource{
+		Database:        a,
+		BaseTokenSource: baseTokenSource,
+		TelegramID:      telegramID,
+	}
 }
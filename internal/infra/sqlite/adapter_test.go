@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/L11R/masked-email-bot/internal/domain"
+	"go.uber.org/zap"
+)
+
+func newTestAdapter(t *testing.T) *adapter {
+	t.Helper()
+
+	db, err := NewAdapter(zap.NewNop(), &Config{
+		DBFile:              "file::memory:?cache=shared",
+		MigrationsSourceURL: "file://../../../migrations/sqlite",
+		Name:                "sqlite",
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewAdapter: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db.(*adapter)
+}
+
+func TestCreateAndConsumePinRoundTrip(t *testing.T) {
+	a := newTestAdapter(t)
+
+	if err := a.CreateUser(1, "en"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	if err := a.CreatePin(1, "123456", "pending-token", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("CreatePin: %v", err)
+	}
+
+	telegramID, fastmailToken, err := a.ConsumePin("123456")
+	if err != nil {
+		t.Fatalf("ConsumePin: %v", err)
+	}
+
+	if telegramID != 1 {
+		t.Fatalf("expected telegramID 1, got %d", telegramID)
+	}
+	if fastmailToken != "pending-token" {
+		t.Fatalf("expected token %q, got %q", "pending-token", fastmailToken)
+	}
+}
+
+func TestConsumePinIsSingleUse(t *testing.T) {
+	a := newTestAdapter(t)
+
+	if err := a.CreateUser(1, "en"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := a.CreatePin(1, "123456", "pending-token", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("CreatePin: %v", err)
+	}
+
+	if _, _, err := a.ConsumePin("123456"); err != nil {
+		t.Fatalf("first ConsumePin: %v", err)
+	}
+
+	if _, _, err := a.ConsumePin("123456"); err != domain.ErrNoPin {
+		t.Fatalf("expected ErrNoPin on a second consume, got %v", err)
+	}
+}
+
+func TestConsumePinExpired(t *testing.T) {
+	a := newTestAdapter(t)
+
+	if err := a.CreateUser(1, "en"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := a.CreatePin(1, "123456", "pending-token", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("CreatePin: %v", err)
+	}
+
+	if _, _, err := a.ConsumePin("123456"); err != domain.ErrNoPin {
+		t.Fatalf("expected ErrNoPin for an expired pin, got %v", err)
+	}
+}
+
+func TestConsumePinUnknown(t *testing.T) {
+	a := newTestAdapter(t)
+
+	if _, _, err := a.ConsumePin("000000"); err != domain.ErrNoPin {
+		t.Fatalf("expected ErrNoPin for an unknown pin, got %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"database/sql"
+	"github.com/L11R/masked-email-bot/internal/infra/sqlcommon"
+)
+
+// Reencrypt opens every stored Fastmail token with oldSealer and re-seals it
+// with newSealer, letting an encryption key be rotated without downtime.
+// Either sealer may be nil to read or write plaintext.
+func Reencrypt(db *sql.DB, oldSealer, newSealer sqlcommon.Sealer) error {
+	rows, err := db.Query(`SELECT telegram_id, fastmail_token FROM users WHERE fastmail_token IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var toReencrypt []sqlcommon.TokenRow
+	for rows.Next() {
+		var r sqlcommon.TokenRow
+		if err := rows.Scan(&r.TelegramID, &r.Token); err != nil {
+			return err
+		}
+
+		toReencrypt = append(toReencrypt, r)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	reencrypted, err := sqlcommon.Reencrypt(toReencrypt, oldSealer, newSealer)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range reencrypted {
+		if _, err := db.Exec(`UPDATE users SET fastmail_token = ? WHERE telegram_id = ?`, r.Token, r.TelegramID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"github.com/L11R/masked-email-bot/internal/domain"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"go.uber.org/zap"
+	"time"
+)
+
+// listUsersPageSize is how many users are fetched from the database per
+// ListUsers call while broadcasting.
+const listUsersPageSize = 100
+
+// broadcastCommand handles the admin-only /broadcast command. The rest of the
+// message after the command is used as the localization message ID to send.
+func (d *delivery) broadcastCommand(localizer *i18n.Localizer, update tgbotapi.Update) error {
+	if !d.isAdmin(update.Message.From.ID) {
+		return nil
+	}
+
+	messageID := update.Message.CommandArguments()
+	if messageID == "" {
+		msg, err := localizer.Localize(&i18n.LocalizeConfig{MessageID: "broadcast.usage"})
+		if err != nil {
+			return err
+		}
+
+		_, err = d.bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, msg))
+		return err
+	}
+
+	go func() {
+		if err := d.Broadcast(context.Background(), messageID, nil); err != nil {
+			d.logger.Error("Error while broadcasting a message!", zap.Error(err))
+		}
+	}()
+
+	return nil
+}
+
+func (d *delivery) isAdmin(telegramID int64) bool {
+	for _, id := range d.config.AdminIDs {
+		if id == telegramID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Broadcast sends a localized message to every non-blocked user, respecting
+// Telegram's global rate limit and marking users who blocked the bot so
+// future broadcasts skip them.
+func (d *delivery) Broadcast(ctx context.Context, messageID string, args map[string]any) error {
+	var cursor int64
+	for {
+		recipients, nextCursor, err := d.database.ListUsers(ctx, cursor, listUsersPageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, recipient := range recipients {
+			if err := d.limiter.Wait(ctx); err != nil {
+				return err
+			}
+
+			if err := d.sendBroadcast(recipient, messageID, args); err != nil {
+				d.logger.Error("Error while sending a broadcast message!", zap.Int64("telegram_id", recipient.TelegramID), zap.Error(err))
+			}
+		}
+
+		if nextCursor == 0 {
+			return nil
+		}
+		cursor = nextCursor
+	}
+}
+
+func (d *delivery) sendBroadcast(recipient *domain.BroadcastRecipient, messageID string, args map[string]any) error {
+	localizer := i18n.NewLocalizer(d.bundle, recipient.LanguageCode)
+	msg, err := localizer.Localize(&i18n.LocalizeConfig{
+		MessageID:    messageID,
+		TemplateData: args,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = d.bot.Send(tgbotapi.NewMessage(recipient.TelegramID, msg))
+	if err == nil {
+		return nil
+	}
+
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case 429:
+			if apiErr.ResponseParameters.RetryAfter > 0 {
+				time.Sleep(time.Duration(apiErr.ResponseParameters.RetryAfter) * time.Second)
+			}
+			_, err = d.bot.Send(tgbotapi.NewMessage(recipient.TelegramID, msg))
+			return err
+		case 403:
+			return d.database.BlockUser(recipient.TelegramID)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,128 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/L11R/masked-email-bot/internal/domain"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"go.uber.org/zap"
+	"golang.org/x/text/language"
+)
+
+// stubDatabase embeds domain.Database so tests only need to override the
+// methods a given test actually exercises.
+type stubDatabase struct {
+	domain.Database
+	blocked []int64
+}
+
+func (s *stubDatabase) BlockUser(telegramID int64) error {
+	s.blocked = append(s.blocked, telegramID)
+	return nil
+}
+
+func newTestBundleWith(t *testing.T, messageID string) *i18n.Bundle {
+	t.Helper()
+
+	bundle := i18n.NewBundle(language.English)
+	if err := bundle.AddMessages(language.English, &i18n.Message{ID: messageID, Other: "hello"}); err != nil {
+		t.Fatalf("AddMessages: %v", err)
+	}
+
+	return bundle
+}
+
+func newTestBot(t *testing.T, handler http.HandlerFunc) *tgbotapi.BotAPI {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bottest-token/getMe" {
+			writeOK(w, tgbotapi.User{ID: 1, IsBot: true, UserName: "test_bot"})
+			return
+		}
+
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint("test-token", server.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatalf("NewBotAPIWithAPIEndpoint: %v", err)
+	}
+
+	return bot
+}
+
+func writeOK(w http.ResponseWriter, result any) {
+	b, _ := json.Marshal(result)
+	_, _ = fmt.Fprintf(w, `{"ok":true,"result":%s}`, b)
+}
+
+func TestSendBroadcastRetriesOnRateLimit(t *testing.T) {
+	var attempts int
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = fmt.Fprint(w, `{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":0}}`)
+			return
+		}
+
+		writeOK(w, tgbotapi.Message{MessageID: 1})
+	})
+
+	d := &delivery{
+		logger: zap.NewNop(),
+		bot:    bot,
+		bundle: newTestBundleWith(t, "broadcast.test"),
+	}
+
+	recipient := &domain.BroadcastRecipient{TelegramID: 42, LanguageCode: "en"}
+	if err := d.sendBroadcast(recipient, "broadcast.test", nil); err != nil {
+		t.Fatalf("sendBroadcast: %v", err)
+	}
+
+	if attempts != 2 {
+		t.Fatalf("expected sendBroadcast to retry once after a 429, got %d attempts", attempts)
+	}
+}
+
+func TestSendBroadcastBlocksUserOnForbidden(t *testing.T) {
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = fmt.Fprint(w, `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`)
+	})
+
+	db := &stubDatabase{}
+	d := &delivery{
+		logger:   zap.NewNop(),
+		bot:      bot,
+		bundle:   newTestBundleWith(t, "broadcast.test"),
+		database: db,
+	}
+
+	recipient := &domain.BroadcastRecipient{TelegramID: 42, LanguageCode: "en"}
+	if err := d.sendBroadcast(recipient, "broadcast.test", nil); err != nil {
+		t.Fatalf("sendBroadcast: %v", err)
+	}
+
+	if len(db.blocked) != 1 || db.blocked[0] != 42 {
+		t.Fatalf("expected user 42 to be marked blocked, got %v", db.blocked)
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	d := &delivery{config: &Config{AdminIDs: []int64{7, 9}}}
+
+	if !d.isAdmin(7) {
+		t.Fatal("expected 7 to be an admin")
+	}
+	if d.isAdmin(8) {
+		t.Fatal("expected 8 not to be an admin")
+	}
+}
@@ -0,0 +1,51 @@
+package telegram
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"strings"
+)
+
+// langCommand handles "/lang <code>", switching the user's stored language.
+func (d *delivery) langCommand(localizer *i18n.Localizer, update tgbotapi.Update, code string) error {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		msg, err := localizer.Localize(&i18n.LocalizeConfig{MessageID: "lang.usage"})
+		if err != nil {
+			return err
+		}
+
+		_, err = d.bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, msg))
+		return err
+	}
+
+	if err := d.database.UpdateLanguageCode(update.Message.From.ID, code); err != nil {
+		return err
+	}
+
+	localizer = i18n.NewLocalizer(d.bundle, code)
+	msg, err := localizer.Localize(&i18n.LocalizeConfig{MessageID: "lang.changed"})
+	if err != nil {
+		return err
+	}
+
+	_, err = d.bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, msg))
+	return err
+}
+
+// revokeCommand disconnects the user's Fastmail account by clearing their
+// stored token, so they have to link it again before creating new masked
+// emails.
+func (d *delivery) revokeCommand(localizer *i18n.Localizer, update tgbotapi.Update) error {
+	if err := d.database.ClearToken(update.Message.From.ID); err != nil {
+		return err
+	}
+
+	msg, err := localizer.Localize(&i18n.LocalizeConfig{MessageID: "revoke.done"})
+	if err != nil {
+		return err
+	}
+
+	_, err = d.bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, msg))
+	return err
+}
@@ -3,21 +3,55 @@ package telegram
 import (
 	"context"
 	"github.com/L11R/masked-email-bot/internal/domain"
+	"github.com/L11R/masked-email-bot/internal/infra/telegram/router"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2/expirable"
 )
 
+var pinRegexp = regexp.MustCompile(`^\d{6}$`)
+
+// broadcastRateLimit is Telegram's global outgoing message rate limit.
+const broadcastRateLimit = 30
+
+// inlineCacheKey identifies a previously generated masked email by the user
+// who requested it and the normalized inline query prefix.
+type inlineCacheKey struct {
+	telegramID int64
+	prefix     string
+}
+
+// inlineQueryState is the last prefix a user was seen typing, used to
+// debounce rapid keystrokes in inline mode.
+type inlineQueryState struct {
+	prefix string
+	at     time.Time
+}
+
 type delivery struct {
-	logger  *zap.Logger
-	config  *Config
-	bundle  *i18n.Bundle
-	bot     *tgbotapi.BotAPI
-	service domain.Service
+	logger   *zap.Logger
+	config   *Config
+	bundle   *i18n.Bundle
+	bot      *tgbotapi.BotAPI
+	service  domain.Service
+	database domain.Database
+	limiter  *rate.Limiter
+	router   *router.Router
+
+	inlineCache *lru.LRU[inlineCacheKey, *domain.MaskedEmail]
+
+	debounceMu sync.Mutex
+	debounce   map[int64]inlineQueryState
 }
 
-func NewDelivery(logger *zap.Logger, config *Config, bundle *i18n.Bundle, service domain.Service) (domain.Delivery, error) {
+func NewDelivery(logger *zap.Logger, config *Config, bundle *i18n.Bundle, service domain.Service, database domain.Database) (domain.Delivery, error) {
 	bot, err := tgbotapi.NewBotAPI(config.Token)
 	if err != nil {
 		return nil, err
@@ -25,13 +59,55 @@ func NewDelivery(logger *zap.Logger, config *Config, bundle *i18n.Bundle, servic
 
 	bot.Debug = config.Debug
 
-	return &delivery{
-		logger:  logger,
-		config:  config,
-		bundle:  bundle,
-		bot:     bot,
-		service: service,
-	}, nil
+	d := &delivery{
+		logger:      logger,
+		config:      config,
+		bundle:      bundle,
+		bot:         bot,
+		service:     service,
+		database:    database,
+		limiter:     rate.NewLimiter(broadcastRateLimit, broadcastRateLimit),
+		inlineCache: lru.NewLRU[inlineCacheKey, *domain.MaskedEmail](config.InlineCacheSize, nil, config.InlineCacheTTL),
+		debounce:    make(map[int64]inlineQueryState),
+	}
+	d.router = d.newRouter()
+
+	return d, nil
+}
+
+// newRouter wires every command and callback handler into a router.Router,
+// so ListenAndServe itself no longer needs to know about any of them.
+func (d *delivery) newRouter() *router.Router {
+	r := router.New(d.bundle)
+
+	r.RegisterCommand("start", func(_ context.Context, localizer *i18n.Localizer, update tgbotapi.Update, _ router.Args) error {
+		return d.startCommand(localizer, update)
+	})
+	r.RegisterCommand("broadcast", func(_ context.Context, localizer *i18n.Localizer, update tgbotapi.Update, _ router.Args) error {
+		return d.broadcastCommand(localizer, update)
+	})
+	r.RegisterCommand("lang", func(_ context.Context, localizer *i18n.Localizer, update tgbotapi.Update, args router.Args) error {
+		return d.langCommand(localizer, update, args.String("arguments"))
+	})
+	r.RegisterCommand("revoke", func(_ context.Context, localizer *i18n.Localizer, update tgbotapi.Update, _ router.Args) error {
+		return d.revokeCommand(localizer, update)
+	})
+
+	r.Register("id", func(_ context.Context, localizer *i18n.Localizer, update tgbotapi.Update, _ router.Args) error {
+		return d.enableMaskedEmail(localizer, update)
+	})
+	r.Register("prefix", func(_ context.Context, localizer *i18n.Localizer, update tgbotapi.Update, args router.Args) error {
+		return d.generateMaskedEmailWithInlineButton(localizer, update)
+	})
+
+	r.RegisterMessage(func(_ context.Context, localizer *i18n.Localizer, update tgbotapi.Update, _ router.Args) error {
+		return d.anyMessage(localizer, update)
+	})
+	r.RegisterInlineQuery(func(_ context.Context, localizer *i18n.Localizer, update tgbotapi.Update, _ router.Args) error {
+		return d.answerInlineQueryWithEmail(localizer, update)
+	})
+
+	return r
 }
 
 func (d *delivery) ListenAndServe() error {
@@ -40,50 +116,28 @@ func (d *delivery) ListenAndServe() error {
 	updates := d.bot.GetUpdatesChan(updateConfig)
 
 	for update := range updates {
-		switch {
-		case update.Message != nil:
-			localizer := i18n.NewLocalizer(d.bundle, update.Message.From.LanguageCode)
-			if update.Message.IsCommand() {
-				switch update.Message.Command() {
-				case "start":
-					if err := d.startCommand(localizer, update); err != nil {
-						d.logger.Error("Error while handling command!", zap.Error(err))
-					}
-					continue
-				default:
-					if err := d.anyOtherCommand(localizer, update); err != nil {
-						d.logger.Error("Error while handling command!", zap.Error(err))
-					}
-					continue
-				}
-			}
-			if err := d.generateMaskedEmail(localizer, update); err != nil {
-				d.logger.Error("Error while handling a link!", zap.Error(err))
-			}
-		case update.CallbackQuery != nil:
-			localizer := i18n.NewLocalizer(d.bundle, update.CallbackQuery.From.LanguageCode)
-			data := strings.Split(update.CallbackData(), ":")
-			switch data[0] {
-			case "id":
-				if err := d.enableMaskedEmail(localizer, update); err != nil {
-					d.logger.Error("Error while enabling a masked email!", zap.Error(err))
-				}
-			case "prefix":
-				if err := d.generateMaskedEmailWithInlineButton(localizer, update); err != nil {
-					d.logger.Error("Error while generating a masked email!", zap.Error(err))
-				}
-			}
-		case update.InlineQuery != nil:
-			localizer := i18n.NewLocalizer(d.bundle, update.InlineQuery.From.LanguageCode)
-			if err := d.answerInlineQueryWithEmail(localizer, update); err != nil {
-				d.logger.Error("Error while trying to answer inline query!", zap.Error(err))
-			}
+		if err := d.router.Handle(context.Background(), update); err != nil {
+			d.logger.Error("Error while handling an update!", zap.Error(err))
 		}
 	}
 
 	return nil
 }
 
+// anyMessage handles a message with no registered command handler: an
+// unknown command, a verification PIN, or otherwise a Fastmail sharing link.
+func (d *delivery) anyMessage(localizer *i18n.Localizer, update tgbotapi.Update) error {
+	if update.Message.IsCommand() {
+		return d.anyOtherCommand(localizer, update)
+	}
+
+	if pinRegexp.MatchString(strings.TrimSpace(update.Message.Text)) {
+		return d.confirmPin(localizer, update)
+	}
+
+	return d.generateMaskedEmail(localizer, update)
+}
+
 func (d *delivery) Shutdown(_ context.Context) error {
 	d.bot.StopReceivingUpdates()
 	return nil
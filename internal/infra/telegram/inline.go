@@ -0,0 +1,86 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/L11R/masked-email-bot/internal/domain"
+	"github.com/L11R/masked-email-bot/internal/metrics"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// answerInlineQueryWithEmail answers an inline query with a masked email for
+// the query's prefix. It is invoked on every keystroke, so results are
+// cached per (telegramID, prefix) and rapid-fire keystrokes are debounced
+// before a new masked email is created via Fastmail's JMAP endpoint.
+func (d *delivery) answerInlineQueryWithEmail(localizer *i18n.Localizer, update tgbotapi.Update) error {
+	telegramID := update.InlineQuery.From.ID
+	prefix := normalizePrefix(update.InlineQuery.Query)
+
+	key := inlineCacheKey{telegramID: telegramID, prefix: prefix}
+	if email, ok := d.inlineCache.Get(key); ok {
+		metrics.InlineCacheHits.Inc()
+		return d.answerInlineQuery(update, localizer, email)
+	}
+
+	if d.debounced(telegramID, prefix) {
+		// Still typing: answer with no results rather than leaving the client
+		// waiting, but skip hitting Fastmail until the prefix settles.
+		return d.answerInlineQuery(update, localizer, nil)
+	}
+
+	metrics.InlineCacheMisses.Inc()
+
+	email, err := d.service.CreateMaskedEmailWithPrefix(context.Background(), telegramID, prefix)
+	if err != nil {
+		return err
+	}
+
+	d.inlineCache.Add(key, email)
+
+	return d.answerInlineQuery(update, localizer, email)
+}
+
+// debounced reports whether telegramID sent another inline query within the
+// configured debounce window of its last one, regardless of prefix, in which
+// case the caller should wait for a settled keystroke instead of hitting
+// Fastmail on every character typed.
+func (d *delivery) debounced(telegramID int64, prefix string) bool {
+	now := time.Now()
+
+	d.debounceMu.Lock()
+	defer d.debounceMu.Unlock()
+
+	last, seen := d.debounce[telegramID]
+	d.debounce[telegramID] = inlineQueryState{prefix: prefix, at: now}
+
+	return seen && now.Sub(last.at) < d.config.InlineDebounce
+}
+
+func normalizePrefix(query string) string {
+	return strings.ToLower(strings.TrimSpace(query))
+}
+
+// answerInlineQuery answers the inline query with a single result built from
+// email, or with no results at all if email is nil, e.g. while debounced.
+func (d *delivery) answerInlineQuery(update tgbotapi.Update, localizer *i18n.Localizer, email *domain.MaskedEmail) error {
+	var results []interface{}
+	if email != nil {
+		title, err := localizer.Localize(&i18n.LocalizeConfig{MessageID: "inline.title"})
+		if err != nil {
+			return err
+		}
+
+		results = []interface{}{tgbotapi.NewInlineQueryResultArticle(update.InlineQuery.ID, title, email.Email)}
+	}
+
+	_, err := d.bot.Request(tgbotapi.InlineConfig{
+		InlineQueryID: update.InlineQuery.ID,
+		Results:       results,
+		CacheTime:     0,
+	})
+
+	return err
+}
@@ -0,0 +1,61 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestDelivery(debounce time.Duration) *delivery {
+	return &delivery{
+		config:   &Config{InlineDebounce: debounce},
+		debounce: make(map[int64]inlineQueryState),
+	}
+}
+
+func TestDebouncedIgnoresPrefix(t *testing.T) {
+	d := newTestDelivery(time.Minute)
+
+	if d.debounced(1, "alice") {
+		t.Fatal("first query should never be debounced")
+	}
+
+	// A changing prefix within the window must still debounce: the old
+	// behaviour only debounced repeats of the exact same prefix, which the
+	// inline cache already short-circuits, so the hot path of a prefix
+	// changing every keystroke never actually got debounced.
+	if !d.debounced(1, "bob") {
+		t.Fatal("expected a query within the debounce window to be debounced, even with a different prefix")
+	}
+}
+
+func TestDebouncedExpiresAfterWindow(t *testing.T) {
+	d := newTestDelivery(time.Millisecond)
+
+	if d.debounced(1, "alice") {
+		t.Fatal("first query should never be debounced")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if d.debounced(1, "bob") {
+		t.Fatal("expected the debounce window to have elapsed")
+	}
+}
+
+func TestDebouncedIsPerUser(t *testing.T) {
+	d := newTestDelivery(time.Minute)
+
+	if d.debounced(1, "alice") {
+		t.Fatal("first query for user 1 should never be debounced")
+	}
+
+	if d.debounced(2, "alice") {
+		t.Fatal("a different user's first query should never be debounced")
+	}
+}
+
+func TestNormalizePrefix(t *testing.T) {
+	if got := normalizePrefix("  Alice  "); got != "alice" {
+		t.Fatalf("normalizePrefix() = %q, want %q", got, "alice")
+	}
+}
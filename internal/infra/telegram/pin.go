@@ -0,0 +1,58 @@
+package telegram
+
+import (
+	"errors"
+	"github.com/L11R/masked-email-bot/internal/domain"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"go.uber.org/zap"
+	"strings"
+)
+
+// confirmPin handles a message that looks like a six-digit verification PIN.
+// It ties the Fastmail account linked on the OAuth2 callback page to the
+// Telegram account that requested it, closing the CSRF hole that relying on
+// the `state` parameter alone leaves open.
+func (d *delivery) confirmPin(localizer *i18n.Localizer, update tgbotapi.Update) error {
+	pin := strings.TrimSpace(update.Message.Text)
+
+	telegramID, fastmailToken, err := d.database.ConsumePin(pin)
+	if err != nil {
+		if errors.Is(err, domain.ErrNoPin) {
+			msg, err := localizer.Localize(&i18n.LocalizeConfig{MessageID: "pin.invalid"})
+			if err != nil {
+				return err
+			}
+
+			_, err = d.bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, msg))
+			return err
+		}
+
+		return err
+	}
+
+	// The PIN was issued for a different Telegram account than the one
+	// sending it back: do not persist the pending token, it isn't this
+	// chat's to claim.
+	if telegramID != update.Message.From.ID {
+		msg, err := localizer.Localize(&i18n.LocalizeConfig{MessageID: "pin.mismatch"})
+		if err != nil {
+			return err
+		}
+
+		_, err = d.bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, msg))
+		return err
+	}
+
+	if err := d.database.UpdateToken(telegramID, fastmailToken); err != nil {
+		return err
+	}
+
+	msg, err := localizer.Localize(&i18n.LocalizeConfig{MessageID: "pin.confirmed"})
+	if err != nil {
+		return err
+	}
+
+	_, err = d.bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, msg))
+	return err
+}
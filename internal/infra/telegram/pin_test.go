@@ -0,0 +1,133 @@
+package telegram
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/L11R/masked-email-bot/internal/domain"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// pinDatabase stubs only the domain.Database methods confirmPin calls.
+type pinDatabase struct {
+	domain.Database
+
+	consumeTelegramID int64
+	consumeToken      string
+	consumeErr        error
+
+	updatedTelegramID int64
+	updatedToken      string
+	updateTokenCalled bool
+}
+
+func (d *pinDatabase) ConsumePin(pin string) (int64, string, error) {
+	return d.consumeTelegramID, d.consumeToken, d.consumeErr
+}
+
+func (d *pinDatabase) UpdateToken(telegramID int64, fastmailToken string) error {
+	d.updateTokenCalled = true
+	d.updatedTelegramID = telegramID
+	d.updatedToken = fastmailToken
+	return nil
+}
+
+func newPinTestBundle(t *testing.T) *i18n.Bundle {
+	t.Helper()
+
+	bundle := newTestBundleWith(t, "pin.invalid")
+	for _, messageID := range []string{"pin.invalid", "pin.mismatch", "pin.confirmed"} {
+		if err := bundle.AddMessages(bundle.LanguageTags()[0], &i18n.Message{ID: messageID, Other: messageID}); err != nil {
+			t.Fatalf("AddMessages(%s): %v", messageID, err)
+		}
+	}
+
+	return bundle
+}
+
+func newPinUpdate(text string, fromID int64) tgbotapi.Update {
+	return tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			Text: text,
+			Chat: &tgbotapi.Chat{ID: fromID},
+			From: &tgbotapi.User{ID: fromID},
+		},
+	}
+}
+
+func TestConfirmPinSuccess(t *testing.T) {
+	var sentText string
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		sentText = r.FormValue("text")
+		writeOK(w, tgbotapi.Message{MessageID: 1})
+	})
+
+	db := &pinDatabase{consumeTelegramID: 42, consumeToken: "fastmail-token"}
+	d := &delivery{bot: bot, database: db}
+	localizer := i18n.NewLocalizer(newPinTestBundle(t), "en")
+
+	if err := d.confirmPin(localizer, newPinUpdate("123456", 42)); err != nil {
+		t.Fatalf("confirmPin: %v", err)
+	}
+
+	if !db.updateTokenCalled {
+		t.Fatal("expected UpdateToken to be called on a matching PIN")
+	}
+	if db.updatedTelegramID != 42 || db.updatedToken != "fastmail-token" {
+		t.Fatalf("UpdateToken called with (%d, %q), want (42, %q)", db.updatedTelegramID, db.updatedToken, "fastmail-token")
+	}
+	if sentText != "pin.confirmed" {
+		t.Fatalf("expected the confirmation message to be sent, got %q", sentText)
+	}
+}
+
+func TestConfirmPinMismatch(t *testing.T) {
+	var sentText string
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		sentText = r.FormValue("text")
+		writeOK(w, tgbotapi.Message{MessageID: 1})
+	})
+
+	// The PIN was issued for telegram_id 42, but 99 is sending it back.
+	db := &pinDatabase{consumeTelegramID: 42, consumeToken: "fastmail-token"}
+	d := &delivery{bot: bot, database: db}
+	localizer := i18n.NewLocalizer(newPinTestBundle(t), "en")
+
+	if err := d.confirmPin(localizer, newPinUpdate("123456", 99)); err != nil {
+		t.Fatalf("confirmPin: %v", err)
+	}
+
+	if db.updateTokenCalled {
+		t.Fatal("expected UpdateToken not to be called when the Telegram ID doesn't match the PIN's owner")
+	}
+	if sentText != "pin.mismatch" {
+		t.Fatalf("expected the mismatch message to be sent, got %q", sentText)
+	}
+}
+
+func TestConfirmPinInvalidOrExpired(t *testing.T) {
+	var sentText string
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		sentText = r.FormValue("text")
+		writeOK(w, tgbotapi.Message{MessageID: 1})
+	})
+
+	db := &pinDatabase{consumeErr: domain.ErrNoPin}
+	d := &delivery{bot: bot, database: db}
+	localizer := i18n.NewLocalizer(newPinTestBundle(t), "en")
+
+	if err := d.confirmPin(localizer, newPinUpdate("000000", 42)); err != nil {
+		t.Fatalf("confirmPin: %v", err)
+	}
+
+	if db.updateTokenCalled {
+		t.Fatal("expected UpdateToken not to be called for an invalid or expired PIN")
+	}
+	if sentText != "pin.invalid" {
+		t.Fatalf("expected the invalid-pin message to be sent, got %q", sentText)
+	}
+}
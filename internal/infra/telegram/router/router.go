@@ -0,0 +1,181 @@
+// Package router dispatches Telegram commands and callback queries to
+// registered handlers, replacing a hand-rolled switch over
+// strings.Split(update.CallbackData(), ":") that doesn't scale as more
+// callback-driven features are added.
+package router
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// Args holds the arguments parsed from a callback payload or a command,
+// keyed by the name given to their Arg in Register.
+type Args map[string]any
+
+func (a Args) String(name string) string {
+	v, _ := a[name].(string)
+	return v
+}
+
+func (a Args) Int64(name string) int64 {
+	v, _ := a[name].(int64)
+	return v
+}
+
+// Handler processes a dispatched update together with its parsed Args.
+type Handler func(ctx context.Context, localizer *i18n.Localizer, update tgbotapi.Update, args Args) error
+
+type argKind int
+
+const (
+	kindString argKind = iota
+	kindInt64
+)
+
+// Arg describes one positional segment of a callback's ":"-separated payload.
+type Arg struct {
+	name string
+	kind argKind
+}
+
+func StringArg(name string) Arg { return Arg{name: name, kind: kindString} }
+func Int64Arg(name string) Arg  { return Arg{name: name, kind: kindInt64} }
+
+func (a Arg) parse(raw string) (any, error) {
+	switch a.kind {
+	case kindInt64:
+		return strconv.ParseInt(raw, 10, 64)
+	default:
+		return raw, nil
+	}
+}
+
+type route struct {
+	handler Handler
+	args    []Arg
+}
+
+// Router owns both command dispatch (/start, /lang, ...) and callback-query
+// dispatch (keyed by the first ":"-separated segment of the callback data).
+type Router struct {
+	bundle    *i18n.Bundle
+	commands  map[string]route
+	callbacks map[string]route
+
+	message     Handler
+	inlineQuery Handler
+}
+
+func New(bundle *i18n.Bundle) *Router {
+	return &Router{
+		bundle:    bundle,
+		commands:  make(map[string]route),
+		callbacks: make(map[string]route),
+	}
+}
+
+// RegisterCommand registers handler for a "/command" message, e.g. "lang"
+// for "/lang". The raw text after the command is exposed to the handler as
+// args["arguments"].
+func (r *Router) RegisterCommand(command string, handler Handler) {
+	r.commands[command] = route{handler: handler}
+}
+
+// Register registers handler against a callback-data prefix, e.g. "id" for
+// callback data "id:abc123". schema describes the remaining ":"-separated
+// segments; Handle rejects callbacks that don't have enough of them.
+func (r *Router) Register(prefix string, handler Handler, schema ...Arg) {
+	r.callbacks[prefix] = route{handler: handler, args: schema}
+}
+
+// RegisterMessage registers the fallback handler for messages that aren't
+// commands, e.g. a Fastmail sharing link or a verification PIN, and for
+// commands with no registered handler.
+func (r *Router) RegisterMessage(handler Handler) {
+	r.message = handler
+}
+
+// RegisterInlineQuery registers the handler for inline queries.
+func (r *Router) RegisterInlineQuery(handler Handler) {
+	r.inlineQuery = handler
+}
+
+// Handle dispatches a single update: a command to its RegisterCommand
+// handler, a callback query to its Register handler, an inline query to the
+// RegisterInlineQuery handler, and any other message, including a command
+// with no registered handler, to the RegisterMessage handler. It is a no-op
+// when no handler applies, e.g. a callback prefix with no registered handler.
+func (r *Router) Handle(ctx context.Context, update tgbotapi.Update) error {
+	switch {
+	case update.Message != nil && update.Message.IsCommand():
+		rt, ok := r.commands[update.Message.Command()]
+		if !ok {
+			if r.message == nil {
+				return nil
+			}
+
+			localizer := i18n.NewLocalizer(r.bundle, update.Message.From.LanguageCode)
+			return r.message(ctx, localizer, update, nil)
+		}
+
+		localizer := i18n.NewLocalizer(r.bundle, update.Message.From.LanguageCode)
+		return rt.handler(ctx, localizer, update, Args{"arguments": update.Message.CommandArguments()})
+
+	case update.CallbackQuery != nil:
+		segments := strings.Split(update.CallbackData(), ":")
+		rt, ok := r.callbacks[segments[0]]
+		if !ok {
+			return nil
+		}
+
+		args, err := parseArgs(rt.args, segments[1:])
+		if err != nil {
+			return err
+		}
+
+		localizer := i18n.NewLocalizer(r.bundle, update.CallbackQuery.From.LanguageCode)
+		return rt.handler(ctx, localizer, update, args)
+
+	case update.Message != nil:
+		if r.message == nil {
+			return nil
+		}
+
+		localizer := i18n.NewLocalizer(r.bundle, update.Message.From.LanguageCode)
+		return r.message(ctx, localizer, update, nil)
+
+	case update.InlineQuery != nil:
+		if r.inlineQuery == nil {
+			return nil
+		}
+
+		localizer := i18n.NewLocalizer(r.bundle, update.InlineQuery.From.LanguageCode)
+		return r.inlineQuery(ctx, localizer, update, nil)
+	}
+
+	return nil
+}
+
+func parseArgs(schema []Arg, raw []string) (Args, error) {
+	args := make(Args, len(schema))
+	for i, a := range schema {
+		if i >= len(raw) {
+			return nil, fmt.Errorf("router: missing argument %q", a.name)
+		}
+
+		v, err := a.parse(raw[i])
+		if err != nil {
+			return nil, fmt.Errorf("router: invalid argument %q: %w", a.name, err)
+		}
+
+		args[a.name] = v
+	}
+
+	return args, nil
+}
@@ -0,0 +1,98 @@
+package router
+
+import (
+	"context"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+func newTestBundle() *i18n.Bundle {
+	return i18n.NewBundle(nil)
+}
+
+func TestRouterDispatchesCallbackWithArgs(t *testing.T) {
+	r := New(newTestBundle())
+
+	var gotID string
+	r.Register("id", func(_ context.Context, _ *i18n.Localizer, _ tgbotapi.Update, args Args) error {
+		gotID = args.String("emailID")
+		return nil
+	}, StringArg("emailID"))
+
+	update := tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			Data: "id:abc123",
+			From: &tgbotapi.User{},
+		},
+	}
+
+	if err := r.Handle(context.Background(), update); err != nil {
+		t.Fatalf("Handle() returned error: %v", err)
+	}
+
+	if gotID != "abc123" {
+		t.Fatalf("expected emailID %q, got %q", "abc123", gotID)
+	}
+}
+
+func TestRouterCallbackMissingArgument(t *testing.T) {
+	r := New(newTestBundle())
+
+	r.Register("id", func(_ context.Context, _ *i18n.Localizer, _ tgbotapi.Update, _ Args) error {
+		return nil
+	}, StringArg("emailID"))
+
+	update := tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			Data: "id",
+			From: &tgbotapi.User{},
+		},
+	}
+
+	if err := r.Handle(context.Background(), update); err == nil {
+		t.Fatal("expected an error for a missing argument, got nil")
+	}
+}
+
+func TestRouterDispatchesCommand(t *testing.T) {
+	r := New(newTestBundle())
+
+	var called bool
+	r.RegisterCommand("start", func(_ context.Context, _ *i18n.Localizer, _ tgbotapi.Update, _ Args) error {
+		called = true
+		return nil
+	})
+
+	update := tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			Text:     "/start",
+			Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 6}},
+			From:     &tgbotapi.User{},
+		},
+	}
+
+	if err := r.Handle(context.Background(), update); err != nil {
+		t.Fatalf("Handle() returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("expected the /start handler to be called")
+	}
+}
+
+func TestRouterUnknownCallbackIsNoop(t *testing.T) {
+	r := New(newTestBundle())
+
+	update := tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			Data: "nope:1",
+			From: &tgbotapi.User{},
+		},
+	}
+
+	if err := r.Handle(context.Background(), update); err != nil {
+		t.Fatalf("Handle() returned error for an unregistered prefix: %v", err)
+	}
+}
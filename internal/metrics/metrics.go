@@ -0,0 +1,19 @@
+// Package metrics holds the Prometheus collectors exposed by the bot.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	InlineCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "inline_cache_hits_total",
+		Help: "Number of inline queries answered from the masked email cache.",
+	})
+
+	InlineCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "inline_cache_misses_total",
+		Help: "Number of inline queries that required creating a new masked email.",
+	})
+)